@@ -0,0 +1,22 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// resolveAddValue turns the VALUE argument to `sctl add` into plaintext
+// bytes: "-" reads stdin, "@path" reads a file, anything else is used
+// literally. This lets callers avoid putting long or binary secrets on the
+// argv, where they'd leak into `ps` and shell history.
+func resolveAddValue(arg string) ([]byte, error) {
+	switch {
+	case arg == "-":
+		return ioutil.ReadAll(os.Stdin)
+	case strings.HasPrefix(arg, "@"):
+		return ioutil.ReadFile(strings.TrimPrefix(arg, "@"))
+	default:
+		return []byte(arg), nil
+	}
+}
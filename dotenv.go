@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// parseDotenv reads a simple NAME=VALUE dotenv file, skipping blank lines
+// and lines starting with '#', and stripping a single layer of surrounding
+// quotes from the value.
+func parseDotenv(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		entries[name] = unquoteDotenvValue(value)
+	}
+	return entries, scanner.Err()
+}
+
+// unquoteDotenvValue reverses quoteDotenvValue: a double-quoted value has
+// its backslash escapes (\\, \", \n, \r) undone, a single-quoted value is
+// taken literally, and anything else is returned as-is.
+func unquoteDotenvValue(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		inner := value[1 : len(value)-1]
+		return strings.NewReplacer(
+			`\"`, `"`,
+			`\n`, "\n",
+			`\r`, "\r",
+			`\\`, `\`,
+		).Replace(inner)
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
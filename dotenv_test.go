@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestQuoteUnquoteDotenvRoundTrip(t *testing.T) {
+	cases := []string{
+		"hunter2",
+		"",
+		"multi\nline\nsecret",
+		`value with "quotes" and \backslash\`,
+		"  leading and trailing space  ",
+		"# looks like a comment",
+		"value\rwith\rcr",
+	}
+	for _, value := range cases {
+		quoted := quoteDotenvValue(value)
+		got := unquoteDotenvValue(quoted)
+		if got != value {
+			t.Fatalf("round trip failed: quoteDotenvValue(%q) = %q, unquoteDotenvValue = %q", value, quoted, got)
+		}
+	}
+}
+
+func TestParseDotenvRoundTrip(t *testing.T) {
+	secrets := map[string]string{
+		"SIMPLE": "value",
+		"PEM": "-----BEGIN KEY-----\nline1\nline2\n-----END KEY-----",
+		"WITH_HASH": "#not-a-comment",
+	}
+
+	rendered, err := formatSecrets(secrets, "dotenv")
+	if err != nil {
+		t.Fatalf("formatSecrets: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := ioutil.WriteFile(path, []byte(rendered), 0600); err != nil {
+		t.Fatalf("write dotenv file: %v", err)
+	}
+
+	parsed, err := parseDotenv(path)
+	if err != nil {
+		t.Fatalf("parseDotenv: %v", err)
+	}
+
+	for name, want := range secrets {
+		if got := parsed[name]; got != want {
+			t.Errorf("parsed[%q] = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	osExec "os/exec"
+	"strings"
+	"time"
+)
+
+// editTempDir prefers a memory-backed tmpfs for the decrypted scratch file
+// so secrets never touch disk, falling back to the regular temp dir.
+func editTempDir() string {
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		return "/dev/shm"
+	}
+	return os.TempDir()
+}
+
+// editSecrets decrypts every secret into a 600-permission scratch file,
+// opens it in $EDITOR, then re-encrypts only the values the user changed
+// via key_svc before writing .scuttle.json back out.
+func editSecrets(key_svc KeyService) error {
+	secrets := readSecrets()
+	dek_cache := newDEKCache(key_svc)
+
+	original := map[string]string{}
+	for _, secret := range secrets {
+		plaintext, err := dek_cache.open(secret)
+		if err != nil {
+			return err
+		}
+		original[secret.Name] = string(plaintext)
+	}
+
+	jsonData, err := json.MarshalIndent(original, "", " ")
+	if err != nil {
+		return err
+	}
+
+	tmp_file, err := ioutil.TempFile(editTempDir(), "sctl-edit-")
+	if err != nil {
+		return err
+	}
+	tmp_path := tmp_file.Name()
+	defer os.Remove(tmp_path)
+
+	if err := os.Chmod(tmp_path, 0600); err != nil {
+		tmp_file.Close()
+		return err
+	}
+	if _, err := tmp_file.Write(jsonData); err != nil {
+		tmp_file.Close()
+		return err
+	}
+	tmp_file.Close()
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := osExec.Command(editor, tmp_path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	edited_raw := map[string]string{}
+	edited_bytes, err := ioutil.ReadFile(tmp_path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(edited_bytes, &edited_raw); err != nil {
+		return err
+	}
+	// Normalize names the same way add/import do, so renaming case in the
+	// editor can't produce a duplicate-looking entry.
+	edited := map[string]string{}
+	for name, plaintext := range edited_raw {
+		edited[strings.ToUpper(name)] = plaintext
+	}
+
+	for name := range original {
+		if _, stillPresent := edited[name]; !stillPresent {
+			rmSecret(name)
+		}
+	}
+
+	for name, plaintext := range edited {
+		if existing, unchanged := original[name]; unchanged && existing == plaintext {
+			continue
+		}
+		encryptedDEK, nonce, ciphertext, err := envelopeSeal(key_svc, []byte(plaintext))
+		if err != nil {
+			return err
+		}
+		addSecret(Secret{
+			Name:         name,
+			EncryptedDEK: encryptedDEK,
+			Nonce:        nonce,
+			Ciphertext:   ciphertext,
+			KeyID:        key_svc.KeyID(),
+			Created:      time.Now(),
+		})
+	}
+
+	return nil
+}
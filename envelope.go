@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	b64 "encoding/base64"
+	"fmt"
+)
+
+// dekSize is the length in bytes of the locally-generated AES-256 data
+// encryption key used to envelope-encrypt each secret.
+const dekSize = 32
+
+// generateDEK returns a random 32-byte data encryption key.
+func generateDEK() ([]byte, error) {
+	dek := make([]byte, dekSize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	return dek, nil
+}
+
+// sealWithDEK AES-GCM encrypts plaintext under dek, returning the nonce and
+// ciphertext separately so they can be stored side by side in .scuttle.json.
+func sealWithDEK(dek, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	ciphertext = gcm.Seal(nil, nonce, plaintext, nil)
+	return nonce, ciphertext, nil
+}
+
+// openWithDEK reverses sealWithDEK.
+func openWithDEK(dek, nonce, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("envelope: nonce is %d bytes, want %d", len(nonce), gcm.NonceSize())
+	}
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+// envelopeSeal generates a fresh DEK, seals plaintext with it locally, then
+// wraps the DEK with key_svc. It returns the three base64 fields that get
+// stored on a Secret.
+func envelopeSeal(key_svc KeyService, plaintext []byte) (encryptedDEK, nonce, ciphertext string, err error) {
+	dek, err := generateDEK()
+	if err != nil {
+		return "", "", "", err
+	}
+	rawNonce, rawCiphertext, err := sealWithDEK(dek, plaintext)
+	if err != nil {
+		return "", "", "", err
+	}
+	wrapped_dek, err := key_svc.Encrypt(dek)
+	if err != nil {
+		return "", "", "", err
+	}
+	return b64.StdEncoding.EncodeToString(wrapped_dek),
+		b64.StdEncoding.EncodeToString(rawNonce),
+		b64.StdEncoding.EncodeToString(rawCiphertext),
+		nil
+}
+
+// envelopeOpen unwraps a Secret's DEK with key_svc then decrypts its
+// ciphertext locally.
+func envelopeOpen(key_svc KeyService, s Secret) ([]byte, error) {
+	wrapped_dek, err := b64.StdEncoding.DecodeString(s.EncryptedDEK)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := b64.StdEncoding.DecodeString(s.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := b64.StdEncoding.DecodeString(s.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := key_svc.Decrypt(wrapped_dek)
+	if err != nil {
+		return nil, err
+	}
+	return openWithDEK(dek, nonce, ciphertext)
+}
+
+// dekCache memoizes unwrapped DEKs for the lifetime of a single command,
+// keyed by the secret's wrapped DEK, so re-reading the same .scuttle.json
+// within one process only calls out to KMS once per unique DEK.
+type dekCache struct {
+	key_svc KeyService
+	dek     map[string][]byte
+}
+
+func newDEKCache(key_svc KeyService) *dekCache {
+	return &dekCache{key_svc: key_svc, dek: map[string][]byte{}}
+}
+
+// open decrypts s using a cached DEK when available, unwrapping and caching
+// it via KMS otherwise.
+func (c *dekCache) open(s Secret) ([]byte, error) {
+	dek, ok := c.dek[s.EncryptedDEK]
+	if !ok {
+		wrapped_dek, err := b64.StdEncoding.DecodeString(s.EncryptedDEK)
+		if err != nil {
+			return nil, err
+		}
+		dek, err = c.key_svc.Decrypt(wrapped_dek)
+		if err != nil {
+			return nil, err
+		}
+		c.dek[s.EncryptedDEK] = dek
+	}
+	nonce, err := b64.StdEncoding.DecodeString(s.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := b64.StdEncoding.DecodeString(s.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return openWithDEK(dek, nonce, ciphertext)
+}
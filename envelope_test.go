@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestSealOpenWithDEK(t *testing.T) {
+	dek, err := generateDEK()
+	if err != nil {
+		t.Fatalf("generateDEK: %v", err)
+	}
+	plaintext := []byte("super secret value")
+
+	nonce, ciphertext, err := sealWithDEK(dek, plaintext)
+	if err != nil {
+		t.Fatalf("sealWithDEK: %v", err)
+	}
+	if string(ciphertext) == string(plaintext) {
+		t.Fatal("ciphertext equals plaintext, encryption did nothing")
+	}
+
+	got, err := openWithDEK(dek, nonce, ciphertext)
+	if err != nil {
+		t.Fatalf("openWithDEK: %v", err)
+	}
+	if string(got) != string(plaintext) {
+		t.Fatalf("openWithDEK = %q, want %q", got, plaintext)
+	}
+}
+
+func TestOpenWithDEKBadNonce(t *testing.T) {
+	dek, _ := generateDEK()
+	_, ciphertext, _ := sealWithDEK(dek, []byte("hello"))
+	if _, err := openWithDEK(dek, []byte("too short"), ciphertext); err == nil {
+		t.Fatal("expected error for wrong-size nonce, got nil")
+	}
+}
+
+func TestEnvelopeSealOpenRoundTrip(t *testing.T) {
+	keySvc := &fakeKeyService{id: "test://key"}
+	plaintext := []byte("multi\nline\nsecret")
+
+	encryptedDEK, nonce, ciphertext, err := envelopeSeal(keySvc, plaintext)
+	if err != nil {
+		t.Fatalf("envelopeSeal: %v", err)
+	}
+
+	got, err := envelopeOpen(keySvc, Secret{
+		EncryptedDEK: encryptedDEK,
+		Nonce:        nonce,
+		Ciphertext:   ciphertext,
+	})
+	if err != nil {
+		t.Fatalf("envelopeOpen: %v", err)
+	}
+	if string(got) != string(plaintext) {
+		t.Fatalf("envelopeOpen = %q, want %q", got, plaintext)
+	}
+}
+
+func TestDEKCacheReusesUnwrappedDEK(t *testing.T) {
+	keySvc := &fakeKeyService{id: "test://key"}
+	encryptedDEK, nonce, ciphertext, err := envelopeSeal(keySvc, []byte("value"))
+	if err != nil {
+		t.Fatalf("envelopeSeal: %v", err)
+	}
+	secret := Secret{EncryptedDEK: encryptedDEK, Nonce: nonce, Ciphertext: ciphertext}
+
+	cache := newDEKCache(keySvc)
+	if _, err := cache.open(secret); err != nil {
+		t.Fatalf("first open: %v", err)
+	}
+	if len(cache.dek) != 1 {
+		t.Fatalf("expected 1 cached DEK, got %d", len(cache.dek))
+	}
+	if _, err := cache.open(secret); err != nil {
+		t.Fatalf("second open: %v", err)
+	}
+	if len(cache.dek) != 1 {
+		t.Fatalf("expected cache to still have 1 entry after repeat open, got %d", len(cache.dek))
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"text/template"
+)
+
+// renderExecFile renders the Go template at templatePath with secrets (name
+// to decrypted plaintext) and writes the result to a new 0600 temp file
+// under the same tmpfs-preferring directory editSecrets uses, returning its
+// path. Callers are responsible for removing it when done; in --background
+// mode the process exits before the child does, so the file is left behind
+// until the tmpfs is cleared or an operator cleans it up.
+func renderExecFile(templatePath string, secrets map[string]string) (string, error) {
+	tmplBytes, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("exec-file").Parse(string(tmplBytes))
+	if err != nil {
+		return "", err
+	}
+
+	out, err := ioutil.TempFile(editTempDir(), "sctl-exec-file-")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if err := os.Chmod(out.Name(), 0600); err != nil {
+		return "", err
+	}
+	if err := tmpl.Execute(out, secrets); err != nil {
+		return "", err
+	}
+	return out.Name(), nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderExecFile(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "config.tmpl")
+	if err := ioutil.WriteFile(tmplPath, []byte("user={{.DB_USER}}\npass={{.DB_PASS}}\n"), 0644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	path, err := renderExecFile(tmplPath, map[string]string{
+		"DB_USER": "alice",
+		"DB_PASS": "hunter2",
+	})
+	if err != nil {
+		t.Fatalf("renderExecFile: %v", err)
+	}
+	defer os.Remove(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat rendered file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("rendered file mode = %o, want 0600", perm)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read rendered file: %v", err)
+	}
+	want := "user=alice\npass=hunter2\n"
+	if string(got) != want {
+		t.Fatalf("rendered file = %q, want %q", got, want)
+	}
+}
+
+func TestRenderExecFileMissingTemplate(t *testing.T) {
+	if _, err := renderExecFile(filepath.Join(t.TempDir(), "nope.tmpl"), nil); err == nil {
+		t.Fatal("expected error for missing template file, got nil")
+	}
+}
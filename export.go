@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// formatSecrets renders name->plaintext as dotenv, json, or yaml for
+// `sctl export`.
+func formatSecrets(secrets map[string]string, format string) (string, error) {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch format {
+	case "", "dotenv":
+		var b strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s=%s\n", name, quoteDotenvValue(secrets[name]))
+		}
+		return b.String(), nil
+	case "json":
+		jsonData, err := json.MarshalIndent(secrets, "", " ")
+		if err != nil {
+			return "", err
+		}
+		return string(jsonData) + "\n", nil
+	case "yaml":
+		var b strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s: %q\n", name, secrets[name])
+		}
+		return b.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q, want dotenv, json, or yaml", format)
+	}
+}
+
+// mustQuoteDotenv reports whether value needs explicit double-quoting to
+// round-trip through a dotenv file: embedded newlines, a leading/trailing
+// '#' or whitespace, or characters parseDotenv would otherwise mishandle.
+func mustQuoteDotenv(value string) bool {
+	if value == "" {
+		return false
+	}
+	if strings.ContainsAny(value, "\n\r\t\"'#") {
+		return true
+	}
+	trimmed := strings.TrimSpace(value)
+	return trimmed != value
+}
+
+// quoteDotenvValue renders value for a dotenv NAME=VALUE line, double-
+// quoting and backslash-escaping it when necessary so multi-line secrets
+// (e.g. PEM keys) and values containing '#' or stray whitespace round-trip
+// through parseDotenv instead of being silently mangled.
+func quoteDotenvValue(value string) string {
+	if !mustQuoteDotenv(value) {
+		return value
+	}
+	escaped := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+	).Replace(value)
+	return `"` + escaped + `"`
+}
@@ -0,0 +1,28 @@
+package main
+
+// fakeKeyService is a KeyService test double that "wraps" by reversing the
+// input bytes, so Encrypt/Decrypt are cheap, deterministic, and easy to
+// assert on without any real KMS.
+type fakeKeyService struct {
+	id string
+}
+
+func (f *fakeKeyService) KeyID() string {
+	return f.id
+}
+
+func (f *fakeKeyService) Encrypt(plaintext []byte) ([]byte, error) {
+	return reverseBytes(plaintext), nil
+}
+
+func (f *fakeKeyService) Decrypt(ciphertext []byte) ([]byte, error) {
+	return reverseBytes(ciphertext), nil
+}
+
+func reverseBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// EncryptedFile is the on-disk envelope format used by `sctl encrypt`/`sctl
+// decrypt`, letting whole config files be checked into git the way
+// sops-style workflows do.
+type EncryptedFile struct {
+	EncryptedDEK string `json:"encrypted_dek"`
+	Nonce        string `json:"nonce"`
+	Ciphertext   string `json:"ciphertext"`
+	KeyID        string `json:"key_id"`
+}
+
+// encryptFile envelope-encrypts the contents of path with key_svc and
+// overwrites path in place with the JSON-serialized EncryptedFile.
+func encryptFile(key_svc KeyService, path string) error {
+	plaintext, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	encryptedDEK, nonce, ciphertext, err := envelopeSeal(key_svc, plaintext)
+	if err != nil {
+		return err
+	}
+	ef := EncryptedFile{
+		EncryptedDEK: encryptedDEK,
+		Nonce:        nonce,
+		Ciphertext:   ciphertext,
+		KeyID:        key_svc.KeyID(),
+	}
+	jsonData, err := json.MarshalIndent(&ef, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, jsonData, 0660)
+}
+
+// decryptFile reads the EncryptedFile envelope at path and returns its
+// decrypted plaintext.
+func decryptFile(key_svc KeyService, path string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ef EncryptedFile
+	if err := json.Unmarshal(raw, &ef); err != nil {
+		return nil, err
+	}
+	return envelopeOpen(key_svc, Secret{
+		EncryptedDEK: ef.EncryptedDEK,
+		Nonce:        ef.Nonce,
+		Ciphertext:   ef.Ciphertext,
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyService abstracts a KMS-backed key so sctl can encrypt/decrypt secrets
+// without caring which cloud (or vault) actually holds the key material.
+type KeyService interface {
+	// Encrypt returns the ciphertext for plaintext under this service's key.
+	Encrypt(plaintext []byte) ([]byte, error)
+	// Decrypt returns the plaintext for ciphertext under this service's key.
+	Decrypt(ciphertext []byte) ([]byte, error)
+	// KeyID returns an identifier for the key behind this service. The
+	// built-in backends derive it from the reference they were constructed
+	// with, without the URI scheme: GCP and AWS return that reference as
+	// given, Azure returns the vault URL and key name (no version), and
+	// Vault returns the transit mount and key name.
+	KeyID() string
+}
+
+// NewKeyService selects a KeyService implementation based on the URI scheme
+// of keyURI, e.g. "gcpkms://...", "awskms://...", "azurekv://...", or
+// "vault://host/transit/keys/NAME". If SCTL_KEYSERVICE_SOCK points at a
+// running `sctl keyservice` daemon backed by keyURI, it's used instead of
+// constructing a KMS client directly.
+func NewKeyService(keyURI string) (KeyService, error) {
+	if sock_path := os.Getenv("SCTL_KEYSERVICE_SOCK"); sock_path != "" {
+		remote, err := dialKeyService(sock_path, keyURI)
+		if err == nil {
+			return remote, nil
+		}
+		if errors.Is(err, errKeyServiceMismatch) {
+			return nil, err
+		}
+	}
+	return newDirectKeyService(keyURI)
+}
+
+// newDirectKeyService builds a KeyService straight from keyURI's scheme,
+// bypassing any running key service daemon. Used by `sctl keyservice` itself
+// to construct the backend it serves.
+func newDirectKeyService(keyURI string) (KeyService, error) {
+	switch {
+	case strings.HasPrefix(keyURI, "gcpkms://"):
+		return NewGCPKeyService(strings.TrimPrefix(keyURI, "gcpkms://"))
+	case strings.HasPrefix(keyURI, "awskms://"):
+		return NewAWSKeyService(strings.TrimPrefix(keyURI, "awskms://"))
+	case strings.HasPrefix(keyURI, "azurekv://"):
+		return NewAzureKeyService(strings.TrimPrefix(keyURI, "azurekv://"))
+	case strings.HasPrefix(keyURI, "vault://"):
+		return NewVaultKeyService(strings.TrimPrefix(keyURI, "vault://"))
+	case keyURI == "":
+		return nil, fmt.Errorf("no --key specified, expected a gcpkms://, awskms://, azurekv://, or vault:// URI")
+	default:
+		// No recognized scheme: assume a bare GCP KMS resource name for
+		// backwards compatibility with existing --key values.
+		return NewGCPKeyService(keyURI)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+)
+
+// errKeyServiceMismatch is returned by dialKeyService when a running daemon
+// answers but is backed by a different key than the one requested. Callers
+// must treat this as fatal rather than silently falling back to a direct
+// KMS client for the requested key.
+var errKeyServiceMismatch = errors.New("sctl keyservice key mismatch")
+
+// remoteKeyService is a KeyService that proxies Encrypt/Decrypt to a running
+// `sctl keyservice` daemon over its unix socket, instead of constructing a
+// KMS client in-process.
+type remoteKeyService struct {
+	client *rpc.Client
+	keyID  string
+}
+
+// dialKeyService connects to the key service daemon listening on sock_path
+// and verifies it's actually backed by keyID before handing back a
+// KeyService for it, so a daemon running for one key can never be
+// silently substituted for another --key.
+func dialKeyService(sock_path, keyID string) (*remoteKeyService, error) {
+	client, err := rpc.Dial("unix", sock_path)
+	if err != nil {
+		return nil, err
+	}
+	var reply KeyIDReply
+	if err := client.Call("KeyService.KeyID", KeyIDArgs{}, &reply); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if reply.KeyID != keyID {
+		client.Close()
+		return nil, fmt.Errorf("%w: daemon at %s is backed by %q, not requested key %q", errKeyServiceMismatch, sock_path, reply.KeyID, keyID)
+	}
+	return &remoteKeyService{client: client, keyID: keyID}, nil
+}
+
+func (r *remoteKeyService) KeyID() string {
+	return r.keyID
+}
+
+func (r *remoteKeyService) Encrypt(plaintext []byte) ([]byte, error) {
+	var reply EncryptReply
+	err := r.client.Call("KeyService.Encrypt", EncryptArgs{Plaintext: plaintext}, &reply)
+	return reply.Ciphertext, err
+}
+
+func (r *remoteKeyService) Decrypt(ciphertext []byte) ([]byte, error) {
+	var reply DecryptReply
+	err := r.client.Call("KeyService.Decrypt", DecryptArgs{Ciphertext: ciphertext}, &reply)
+	return reply.Plaintext, err
+}
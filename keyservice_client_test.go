@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDialKeyServiceMatchingKey(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "sctl.sock")
+	go RunKeyServiceDaemon(sockPath, &fakeKeyService{id: "test://key"})
+	waitForSocket(t, sockPath)
+
+	remote, err := dialKeyService(sockPath, "test://key")
+	if err != nil {
+		t.Fatalf("dialKeyService: %v", err)
+	}
+	if remote.KeyID() != "test://key" {
+		t.Fatalf("remote.KeyID() = %q, want %q", remote.KeyID(), "test://key")
+	}
+}
+
+func TestDialKeyServiceMismatchedKey(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "sctl.sock")
+	go RunKeyServiceDaemon(sockPath, &fakeKeyService{id: "test://key"})
+	waitForSocket(t, sockPath)
+
+	_, err := dialKeyService(sockPath, "test://other-key")
+	if err == nil {
+		t.Fatal("expected error for mismatched key, got nil")
+	}
+	if !errors.Is(err, errKeyServiceMismatch) {
+		t.Fatalf("expected errKeyServiceMismatch, got %v", err)
+	}
+}
+
+// waitForSocket polls for sockPath to become dialable, since
+// RunKeyServiceDaemon starts listening asynchronously in a test goroutine.
+func waitForSocket(t *testing.T, sockPath string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if client, err := dialKeyService(sockPath, ""); err == nil {
+			client.client.Close()
+			return
+		} else if errors.Is(err, errKeyServiceMismatch) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s to accept connections", sockPath)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// AWSKeyService encrypts and decrypts via an AWS KMS key.
+type AWSKeyService struct {
+	keyID string
+}
+
+// NewAWSKeyService builds a KeyService backed by AWS KMS. keyID is the key
+// ARN, key ID, or alias, e.g. "arn:aws:kms:us-east-1:111122223333:key/...".
+func NewAWSKeyService(keyID string) (*AWSKeyService, error) {
+	return &AWSKeyService{keyID: keyID}, nil
+}
+
+func (a *AWSKeyService) KeyID() string {
+	return a.keyID
+}
+
+func (a *AWSKeyService) Encrypt(plaintext []byte) ([]byte, error) {
+	svc := kms.New(session.Must(session.NewSession()))
+	resp, err := svc.Encrypt(&kms.EncryptInput{
+		KeyId:     aws.String(a.keyID),
+		Plaintext: plaintext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.CiphertextBlob, nil
+}
+
+func (a *AWSKeyService) Decrypt(ciphertext []byte) ([]byte, error) {
+	svc := kms.New(session.Must(session.NewSession()))
+	resp, err := svc.Decrypt(&kms.DecryptInput{
+		KeyId:          aws.String(a.keyID),
+		CiphertextBlob: ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/2016-10-01/keyvault"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+)
+
+// AzureKeyService encrypts and decrypts via an Azure Key Vault key.
+type AzureKeyService struct {
+	vaultBaseURL string
+	keyName      string
+	keyVersion   string
+}
+
+// NewAzureKeyService builds a KeyService backed by Azure Key Vault.
+// keyRef is "VAULT_NAME.vault.azure.net/keys/KEY_NAME[/VERSION]".
+func NewAzureKeyService(keyRef string) (*AzureKeyService, error) {
+	parts := strings.SplitN(keyRef, "/keys/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("azure key URI must look like azurekv://VAULT_NAME.vault.azure.net/keys/KEY_NAME, got %q", keyRef)
+	}
+	nameVersion := strings.SplitN(parts[1], "/", 2)
+	if nameVersion[0] == "" {
+		return nil, fmt.Errorf("azure key URI must look like azurekv://VAULT_NAME.vault.azure.net/keys/KEY_NAME, got %q", keyRef)
+	}
+	svc := &AzureKeyService{vaultBaseURL: "https://" + parts[0], keyName: nameVersion[0]}
+	if len(nameVersion) == 2 {
+		svc.keyVersion = nameVersion[1]
+	}
+	return svc, nil
+}
+
+func (a *AzureKeyService) KeyID() string {
+	return a.vaultBaseURL + "/keys/" + a.keyName
+}
+
+func (a *AzureKeyService) client() (keyvault.BaseClient, error) {
+	client := keyvault.New()
+	authorizer, err := auth.NewAuthorizerFromEnvironment()
+	if err != nil {
+		return client, err
+	}
+	client.Authorizer = authorizer
+	return client, nil
+}
+
+func (a *AzureKeyService) Encrypt(plaintext []byte) ([]byte, error) {
+	client, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	// Key Vault's Value field is base64url (RFC 4648 §5), not standard base64.
+	value := b64.RawURLEncoding.EncodeToString(plaintext)
+	resp, err := client.Encrypt(ctx, a.vaultBaseURL, a.keyName, a.keyVersion, keyvault.KeyOperationsParameters{
+		Algorithm: keyvault.RSA15,
+		Value:     &value,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b64.RawURLEncoding.DecodeString(*resp.Result)
+}
+
+func (a *AzureKeyService) Decrypt(ciphertext []byte) ([]byte, error) {
+	client, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	// Key Vault's Value field is base64url (RFC 4648 §5), not standard base64.
+	value := b64.RawURLEncoding.EncodeToString(ciphertext)
+	resp, err := client.Decrypt(ctx, a.vaultBaseURL, a.keyName, a.keyVersion, keyvault.KeyOperationsParameters{
+		Algorithm: keyvault.RSA15,
+		Value:     &value,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b64.RawURLEncoding.DecodeString(*resp.Result)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+
+	cloudkms "cloud.google.com/go/kms/apiv1"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+// GCPKeyService encrypts and decrypts via a Google Cloud KMS symmetric key.
+type GCPKeyService struct {
+	keyName string
+}
+
+// NewGCPKeyService builds a KeyService backed by Google Cloud KMS.
+// keyName is the resource name, e.g.
+// "projects/PROJECT_ID/locations/global/keyRings/RING_ID/cryptoKeys/KEY_ID".
+func NewGCPKeyService(keyName string) (*GCPKeyService, error) {
+	return &GCPKeyService{keyName: keyName}, nil
+}
+
+func (g *GCPKeyService) KeyID() string {
+	return g.keyName
+}
+
+func (g *GCPKeyService) Encrypt(plaintext []byte) ([]byte, error) {
+	ctx := context.Background()
+	client, err := cloudkms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	req := &kmspb.EncryptRequest{
+		Name:      g.keyName,
+		Plaintext: plaintext,
+	}
+	resp, err := client.Encrypt(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Ciphertext, nil
+}
+
+func (g *GCPKeyService) Decrypt(ciphertext []byte) ([]byte, error) {
+	ctx := context.Background()
+	client, err := cloudkms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	req := &kmspb.DecryptRequest{
+		Name:       g.keyName,
+		Ciphertext: ciphertext,
+	}
+	resp, err := client.Decrypt(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	b64 "encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// VaultKeyService encrypts and decrypts via a HashiCorp Vault Transit key.
+// It authenticates using VAULT_ADDR/VAULT_TOKEN from the environment, or
+// VAULT_ROLE_ID/VAULT_SECRET_ID for AppRole login.
+type VaultKeyService struct {
+	client  *vaultapi.Client
+	keyName string
+}
+
+// NewVaultKeyService builds a KeyService backed by Vault's transit secrets
+// engine. keyRef is "host/transit/keys/NAME"; host is ignored in favor of
+// VAULT_ADDR, which is the canonical way Vault clients are configured.
+func NewVaultKeyService(keyRef string) (*VaultKeyService, error) {
+	parts := strings.Split(strings.Trim(keyRef, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-2] != "keys" {
+		return nil, fmt.Errorf("vault key URI must look like vault://host/transit/keys/NAME, got %q", keyRef)
+	}
+	keyName := parts[len(parts)-1]
+	mount := strings.Join(parts[1:len(parts)-2], "/")
+	if mount == "" {
+		mount = "transit"
+	}
+
+	client, err := vaultapi.NewClient(vaultapi.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	if err := approleLoginIfConfigured(client); err != nil {
+		return nil, err
+	}
+
+	return &VaultKeyService{client: client, keyName: mount + "/" + keyName}, nil
+}
+
+func approleLoginIfConfigured(client *vaultapi.Client) error {
+	roleID := os.Getenv("VAULT_ROLE_ID")
+	secretID := os.Getenv("VAULT_SECRET_ID")
+	if roleID == "" || secretID == "" {
+		return nil
+	}
+	secret, err := client.Logical().Write("auth/approle/login", map[string]interface{}{
+		"role_id":   roleID,
+		"secret_id": secretID,
+	})
+	if err != nil {
+		return err
+	}
+	client.SetToken(secret.Auth.ClientToken)
+	return nil
+}
+
+func (v *VaultKeyService) KeyID() string {
+	return v.keyName
+}
+
+func (v *VaultKeyService) Encrypt(plaintext []byte) ([]byte, error) {
+	mount, name := v.mountAndName()
+	resp, err := v.client.Logical().Write(fmt.Sprintf("%s/encrypt/%s", mount, name), map[string]interface{}{
+		"plaintext": b64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, ok := resp.Data["ciphertext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault transit encrypt response missing ciphertext")
+	}
+	return []byte(ciphertext), nil
+}
+
+func (v *VaultKeyService) Decrypt(ciphertext []byte) ([]byte, error) {
+	mount, name := v.mountAndName()
+	resp, err := v.client.Logical().Write(fmt.Sprintf("%s/decrypt/%s", mount, name), map[string]interface{}{
+		"ciphertext": string(ciphertext),
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded, ok := resp.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault transit decrypt response missing plaintext")
+	}
+	return b64.StdEncoding.DecodeString(encoded)
+}
+
+func (v *VaultKeyService) mountAndName() (string, string) {
+	idx := strings.LastIndex(v.keyName, "/")
+	return v.keyName[:idx], v.keyName[idx+1:]
+}
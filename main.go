@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/google/shlex"
@@ -12,20 +11,25 @@ import (
 	"sort"
 
 	"strings"
+	"syscall"
 	"time"
 
-	cloudkms "cloud.google.com/go/kms/apiv1"
 	b64 "encoding/base64"
-	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
 	exec "os/exec"
 )
 
-// Serialized secret
-// { "name": "A_SECRET", "cypher": "0xD34DB33F", "created": "2019-05-01 13:01:27.189242799 -0500 CDT m=+0.000075907"}
+// Serialized secret, envelope-encrypted: EncryptedDEK is the per-secret data
+// encryption key wrapped by the KMS key, and Ciphertext/Nonce are the
+// secret's plaintext AES-GCM-sealed under that DEK. KeyID records which KMS
+// key wrapped the DEK, so `sctl filestatus` can report it without decrypting.
+// { "name": "A_SECRET", "encrypted_dek": "...", "nonce": "...", "ciphertext": "0xD34DB33F", "key_id": "projects/.../cryptoKeys/KEY_ID", "created": "2019-05-01 13:01:27.189242799 -0500 CDT m=+0.000075907"}
 type Secret struct {
-	Name       string    `json:"name"`
-	Cyphertext string    `json:"cypher"`
-	Created    time.Time `json:"created"`
+	Name         string    `json:"name"`
+	EncryptedDEK string    `json:"encrypted_dek"`
+	Nonce        string    `json:"nonce"`
+	Ciphertext   string    `json:"ciphertext"`
+	KeyID        string    `json:"key_id"`
+	Created      time.Time `json:"created"`
 }
 
 func addSecret(to_add Secret) {
@@ -68,6 +72,11 @@ func readSecrets() []Secret {
 	if err != nil {
 		log.Fatal(err)
 	}
+	for _, secret := range data {
+		if secret.EncryptedDEK == "" {
+			log.Fatalf(".scuttle.json: secret %q uses the pre-envelope-encryption format and cannot be read; migrate it (re-add each secret under the new format) before using this version of sctl", secret.Name)
+		}
+	}
 	return data
 }
 
@@ -81,50 +90,6 @@ func writeSecrets(data []Secret) {
 
 }
 
-// encrypt will encrypt the input plaintext with the specified symmetric key
-// example keyName: "projects/PROJECT_ID/locations/global/keyRings/RING_ID/cryptoKeys/KEY_ID"
-func encryptSymmetric(keyName string, plaintext []byte) ([]byte, error) {
-	ctx := context.Background()
-	client, err := cloudkms.NewKeyManagementClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	// Build the request.
-	req := &kmspb.EncryptRequest{
-		Name:      keyName,
-		Plaintext: plaintext,
-	}
-	// Call the API.
-	resp, err := client.Encrypt(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return resp.Ciphertext, nil
-}
-
-// decrypt will decrypt the input ciphertext bytes using the specified symmetric key
-// example keyName: "projects/PROJECT_ID/locations/global/keyRings/RING_ID/cryptoKeys/KEY_ID"
-func decryptSymmetric(keyName string, ciphertext []byte) ([]byte, error) {
-	ctx := context.Background()
-	client, err := cloudkms.NewKeyManagementClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	// Build the request.
-	req := &kmspb.DecryptRequest{
-		Name:       keyName,
-		Ciphertext: ciphertext,
-	}
-	// Call the API.
-	resp, err := client.Decrypt(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	return resp.Plaintext, nil
-}
-
 func main() {
 	app := cli.NewApp()
 	app.Name = "sctl"
@@ -141,12 +106,12 @@ func main() {
 	app.Commands = []cli.Command{
 		{
 			Name:  "add",
-			Usage: "add secret",
+			Usage: "add secret; VALUE may be '-' to read stdin or '@path' to read a file",
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:   "key",
 					EnvVar: "SCTL_KEY",
-					Usage:  "GCloud KMS Key URI",
+					Usage:  "Key URI, e.g. gcpkms://, awskms://, azurekv://, or vault://",
 				},
 			},
 			Action: func(c *cli.Context) error {
@@ -155,16 +120,25 @@ func main() {
 					return nil
 				}
 				secret_name := c.Args().First()
-				plaintext := []byte(c.Args()[1])
-				cypher, err := encryptSymmetric(c.String("key"), plaintext)
+				plaintext, err := resolveAddValue(c.Args()[1])
+				if err != nil {
+					log.Fatal(err)
+				}
+				key_svc, err := NewKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				encryptedDEK, nonce, ciphertext, err := envelopeSeal(key_svc, plaintext)
 				if err != nil {
 					log.Fatal(err)
 				}
-				encoded := b64.StdEncoding.EncodeToString(cypher)
 				to_add := Secret{
-					Name:       strings.ToUpper(secret_name),
-					Cyphertext: encoded,
-					Created:    time.Now(),
+					Name:         strings.ToUpper(secret_name),
+					EncryptedDEK: encryptedDEK,
+					Nonce:        nonce,
+					Ciphertext:   ciphertext,
+					KeyID:        key_svc.KeyID(),
+					Created:      time.Now(),
 				}
 				addSecret(to_add)
 				return nil
@@ -203,7 +177,24 @@ func main() {
 				cli.StringFlag{
 					Name:   "key",
 					EnvVar: "SCTL_KEY",
-					Usage:  "GCloud KMS Key URI",
+					Usage:  "Key URI, e.g. gcpkms://, awskms://, azurekv://, or vault://",
+				},
+				cli.BoolFlag{
+					Name:  "pristine",
+					Usage: "do not inherit the parent environment, only decrypted secrets and a minimal PATH",
+				},
+				cli.BoolFlag{
+					Name:  "background",
+					Usage: "fork the command, detach it from stdio, and print its PID",
+				},
+				cli.StringFlag{
+					Name:  "exec-file",
+					Usage: "render decrypted secrets through this Go template into a temp file for the child to read",
+				},
+				cli.StringFlag{
+					Name:  "exec-file-env",
+					Value: "SCTL_FILE",
+					Usage: "env var used to pass the --exec-file temp file path to the child",
 				},
 			},
 			Action: func(c *cli.Context) error {
@@ -211,21 +202,62 @@ func main() {
 
 				cmd := exec.Command(c.Args().First())
 				cmd.Args, _ = shlex.Split(strings.Join(c.Args(), ", "))
-				cmd.Env = os.Environ()
+				if c.Bool("pristine") {
+					cmd.Env = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+				} else {
+					cmd.Env = os.Environ()
+				}
 				secrets = readSecrets()
+				key_svc, err := NewKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				dek_cache := newDEKCache(key_svc)
+				plaintext_by_name := map[string]string{}
 				for _, secret := range secrets {
-					// uncan the base64
-					decoded, _ := b64.StdEncoding.DecodeString(secret.Cyphertext)
-					// Decrypt the raw encrypted secret w/ kms
-					cypher, _ := decryptSymmetric(c.String("key"), decoded)
+					// Unwrap the secret's DEK (once per unique DEK) and decrypt locally
+					plaintext, err := dek_cache.open(secret)
+					if err != nil {
+						log.Fatal(err)
+					}
+					plaintext_by_name[secret.Name] = string(plaintext)
 					// Format the decrypted data for ENV consumption
-					skrt := fmt.Sprintf("%s=%s", secret.Name, cypher)
+					skrt := fmt.Sprintf("%s=%s", secret.Name, plaintext)
 					// Append it to the command exec environment
 					cmd.Env = append(cmd.Env, skrt)
 				}
+
+				if exec_file_template := c.String("exec-file"); exec_file_template != "" {
+					path, err := renderExecFile(exec_file_template, plaintext_by_name)
+					if err != nil {
+						log.Fatal(err)
+					}
+					if !c.Bool("background") {
+						defer os.Remove(path)
+					}
+					cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", c.String("exec-file-env"), path))
+				}
+
+				if c.Bool("background") {
+					cmd.Stdin = nil
+					devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+					if err != nil {
+						log.Fatal(err)
+					}
+					defer devNull.Close()
+					cmd.Stdout = devNull
+					cmd.Stderr = devNull
+					cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+					if err := cmd.Start(); err != nil {
+						log.Fatal(err)
+					}
+					fmt.Println(cmd.Process.Pid)
+					return nil
+				}
+
 				cmd.Stdout = os.Stdout
 				cmd.Stderr = os.Stderr
-				err := cmd.Run()
+				err = cmd.Run()
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -233,6 +265,255 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:  "rotate",
+			Usage: "re-wrap all secrets' data keys under a new KMS key",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "key",
+					EnvVar: "SCTL_KEY",
+					Usage:  "current Key URI the secrets were wrapped with",
+				},
+				cli.StringFlag{
+					Name:  "new-key",
+					Usage: "new Key URI to re-wrap every secret's DEK with",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				old_key_svc, err := NewKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				new_key_svc, err := NewKeyService(c.String("new-key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				secrets := readSecrets()
+				for index, secret := range secrets {
+					wrapped_dek, err := b64.StdEncoding.DecodeString(secret.EncryptedDEK)
+					if err != nil {
+						log.Fatal(err)
+					}
+					dek, err := old_key_svc.Decrypt(wrapped_dek)
+					if err != nil {
+						log.Fatal(err)
+					}
+					rewrapped_dek, err := new_key_svc.Encrypt(dek)
+					if err != nil {
+						log.Fatal(err)
+					}
+					secrets[index].EncryptedDEK = b64.StdEncoding.EncodeToString(rewrapped_dek)
+					secrets[index].KeyID = new_key_svc.KeyID()
+					log.Printf("Rotated DEK for %s", secret.Name)
+				}
+				writeSecrets(secrets)
+				return nil
+			},
+		},
+		{
+			Name:  "edit",
+			Usage: "decrypt all secrets into a scratch file, edit them in $EDITOR, and re-encrypt what changed",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "key",
+					EnvVar: "SCTL_KEY",
+					Usage:  "Key URI, e.g. gcpkms://, awskms://, azurekv://, or vault://",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				key_svc, err := NewKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := editSecrets(key_svc); err != nil {
+					log.Fatal(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "encrypt",
+			Usage: "envelope-encrypt an arbitrary file in place",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "key",
+					EnvVar: "SCTL_KEY",
+					Usage:  "Key URI, e.g. gcpkms://, awskms://, azurekv://, or vault://",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if len(c.Args()) < 1 {
+					log.Fatal("Usage: sctl encrypt FILE")
+					return nil
+				}
+				key_svc, err := NewKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := encryptFile(key_svc, c.Args().First()); err != nil {
+					log.Fatal(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "decrypt",
+			Usage: "decrypt a file previously encrypted with sctl encrypt, printing its plaintext to stdout",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "key",
+					EnvVar: "SCTL_KEY",
+					Usage:  "Key URI, e.g. gcpkms://, awskms://, azurekv://, or vault://",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if len(c.Args()) < 1 {
+					log.Fatal("Usage: sctl decrypt FILE")
+					return nil
+				}
+				key_svc, err := NewKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				plaintext, err := decryptFile(key_svc, c.Args().First())
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Print(string(plaintext))
+				return nil
+			},
+		},
+		{
+			Name:  "filestatus",
+			Usage: "report whether .scuttle.json is well-formed, which key wrapped each secret, and creation times",
+			Action: func(c *cli.Context) error {
+				file, err := ioutil.ReadFile(".scuttle.json")
+				if err != nil {
+					fmt.Printf(".scuttle.json: %v\n", err)
+					return nil
+				}
+				var secrets []Secret
+				if err := json.Unmarshal(file, &secrets); err != nil {
+					fmt.Printf(".scuttle.json: malformed: %v\n", err)
+					return nil
+				}
+				fmt.Println(".scuttle.json: well-formed")
+				for _, secret := range secrets {
+					fmt.Printf("%s\twrapped by %s\tcreated %s\n", secret.Name, secret.KeyID, secret.Created)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "keyservice",
+			Usage: "run a local daemon that serves KMS Encrypt/Decrypt/GenerateDataKey over a unix socket",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "key",
+					EnvVar: "SCTL_KEY",
+					Usage:  "Key URI, e.g. gcpkms://, awskms://, azurekv://, or vault://",
+				},
+				cli.StringFlag{
+					Name:   "sock",
+					EnvVar: "SCTL_KEYSERVICE_SOCK",
+					Value:  "/tmp/sctl-keyservice.sock",
+					Usage:  "unix socket path to listen on",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				key_svc, err := newDirectKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := RunKeyServiceDaemon(c.String("sock"), key_svc); err != nil {
+					log.Fatal(err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "import",
+			Usage: "bulk add secrets from a dotenv file",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "key",
+					EnvVar: "SCTL_KEY",
+					Usage:  "Key URI, e.g. gcpkms://, awskms://, azurekv://, or vault://",
+				},
+				cli.StringFlag{
+					Name:  "from-dotenv",
+					Usage: "path to a .env file to import",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				dotenv_path := c.String("from-dotenv")
+				if dotenv_path == "" {
+					log.Fatal("Usage: sctl import --from-dotenv FILE")
+					return nil
+				}
+				key_svc, err := NewKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				entries, err := parseDotenv(dotenv_path)
+				if err != nil {
+					log.Fatal(err)
+				}
+				for name, value := range entries {
+					encryptedDEK, nonce, ciphertext, err := envelopeSeal(key_svc, []byte(value))
+					if err != nil {
+						log.Fatal(err)
+					}
+					addSecret(Secret{
+						Name:         strings.ToUpper(name),
+						EncryptedDEK: encryptedDEK,
+						Nonce:        nonce,
+						Ciphertext:   ciphertext,
+						KeyID:        key_svc.KeyID(),
+						Created:      time.Now(),
+					})
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "export",
+			Usage: "decrypt all secrets and print them in the given format",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "key",
+					EnvVar: "SCTL_KEY",
+					Usage:  "Key URI, e.g. gcpkms://, awskms://, azurekv://, or vault://",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Value: "dotenv",
+					Usage: "output format: dotenv, json, or yaml",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				key_svc, err := NewKeyService(c.String("key"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				dek_cache := newDEKCache(key_svc)
+				secrets := readSecrets()
+				plaintext_by_name := map[string]string{}
+				for _, secret := range secrets {
+					plaintext, err := dek_cache.open(secret)
+					if err != nil {
+						log.Fatal(err)
+					}
+					plaintext_by_name[secret.Name] = string(plaintext)
+				}
+				rendered, err := formatSecrets(plaintext_by_name, c.String("format"))
+				if err != nil {
+					log.Fatal(err)
+				}
+				fmt.Print(rendered)
+				return nil
+			},
+		},
 	}
 
 	err := app.Run(os.Args)